@@ -0,0 +1,58 @@
+package conf
+
+import "time"
+
+// StringDuration is a time.Duration used for duration settings. It defines
+// no marshaling methods of its own, so it is encoded and decoded as a raw
+// integer count of nanoseconds, not as a string such as "10s".
+type StringDuration time.Duration
+
+// IPsOrCIDRs is a list of IP addresses and/or CIDR networks, used to
+// configure access lists such as trusted reverse proxies.
+type IPsOrCIDRs []string
+
+// WebRTCICEServer is the user-facing configuration of a single ICE server,
+// as found in webrtcICEServers2.
+type WebRTCICEServer struct {
+	URL            string `json:"url"`
+	Username       string `json:"username"`
+	Credential     string `json:"credential"`
+	CredentialType string `json:"credentialType"`
+
+	// Secret, when set, makes Username the "user" part of the TURN REST API
+	// HMAC scheme and TTL the lifetime of the credentials it mints, so that
+	// a fresh username/credential pair is used for every session instead of
+	// a static one; Credential is then not used.
+	Secret string         `json:"secret"`
+	TTL    StringDuration `json:"ttl"`
+}
+
+// Conf is the subset of the application configuration that the WebRTC
+// server is built from.
+type Conf struct {
+	WebRTCAddress        string            `json:"webrtcAddress"`
+	WebRTCEncryption     bool              `json:"webrtcEncryption"`
+	WebRTCServerKey      string            `json:"webrtcServerKey"`
+	WebRTCServerCert     string            `json:"webrtcServerCert"`
+	WebRTCAllowOrigin    string            `json:"webrtcAllowOrigin"`
+	WebRTCTrustedProxies IPsOrCIDRs        `json:"webrtcTrustedProxies"`
+	WebRTCICEServers2    []WebRTCICEServer `json:"webrtcICEServers2"`
+
+	ReadTimeout     StringDuration `json:"readTimeout"`
+	ReadBufferCount int            `json:"readBufferCount"`
+
+	WebRTCPLIInterval      StringDuration `json:"webrtcPLIInterval"`
+	WebRTCICEUDPMuxAddress string         `json:"webrtcICEUDPMuxAddress"`
+	WebRTCICETCPMuxAddress string         `json:"webrtcICETCPMuxAddress"`
+
+	// WebRTCICEPortMin and WebRTCICEPortMax, when non-zero, restrict the
+	// local UDP port range used for ICE candidates, so that it can be
+	// pinned open through a firewall instead of every ephemeral port.
+	WebRTCICEPortMin uint16 `json:"webrtcICEPortMin"`
+	WebRTCICEPortMax uint16 `json:"webrtcICEPortMax"`
+
+	// WebRTCICEPublicIPs lists the public IPs to advertise as host ICE
+	// candidates, for deployments behind a static 1:1 NAT where STUN can't
+	// discover the public address.
+	WebRTCICEPublicIPs []string `json:"webrtcICEPublicIPs"`
+}
@@ -0,0 +1,595 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/websocket"
+)
+
+// webRTCHandshakeConn abstracts the two ways a webRTCConn can exchange an
+// SDP offer/answer pair with the remote peer: the legacy browser-oriented
+// WebSocket signaling, and the WHIP/WHEP HTTP signaling added later.
+type webRTCHandshakeConn interface {
+	// readOffer returns the SDP offer sent by the remote peer.
+	readOffer() (string, error)
+
+	// writeAnswer sends the SDP answer back to the remote peer.
+	writeAnswer(answer string) error
+}
+
+// webRTCWSHandshake implements webRTCHandshakeConn over a websocket, as used
+// by the browser-facing /<path>/ws and /<path>/publish/ws pages.
+type webRTCWSHandshake struct {
+	wsconn *websocket.ServerConn
+}
+
+func (h webRTCWSHandshake) readOffer() (string, error) {
+	return h.wsconn.ReadOffer()
+}
+
+func (h webRTCWSHandshake) writeAnswer(answer string) error {
+	return h.wsconn.WriteAnswer(answer)
+}
+
+// webRTCHTTPHandshake implements webRTCHandshakeConn over a single HTTP
+// request/response pair, as used by WHIP/WHEP.
+type webRTCHTTPHandshake struct {
+	offer  string
+	answer chan string
+}
+
+func (h webRTCHTTPHandshake) readOffer() (string, error) {
+	return h.offer, nil
+}
+
+func (h webRTCHTTPHandshake) writeAnswer(answer string) error {
+	h.answer <- answer
+	return nil
+}
+
+// stringAddr implements net.Addr over a plain "host:port" string, as
+// extracted from an *http.Request, where no richer net.Addr is available.
+type stringAddr string
+
+func (a stringAddr) Network() string { return "tcp" }
+func (a stringAddr) String() string  { return string(a) }
+
+type webRTCConnParent interface {
+	logger.Writer
+	connClose(*webRTCConn)
+
+	// connByPathName returns the publish connection currently serving
+	// pathName, or nil if there is none, so that a reading connection can
+	// attach to its incoming tracks.
+	connByPathName(pathName string) *webRTCConn
+}
+
+// webRTCIncomingTrack pairs a publisher's incoming track with the local
+// track that republishes its RTP packets to readers.
+type webRTCIncomingTrack struct {
+	remote *webrtc.TrackRemote
+	local  *webrtc.TrackLocalStaticRTP
+}
+
+// webRTCConn represents a single WHIP/WHEP/WebSocket WebRTC session.
+//
+// Publishing and reading are currently a self-contained WebRTC-to-WebRTC
+// relay: onTrack forwards a publisher's RTP packets straight to readers of
+// the same path, found through the server's connByPathName, instead of
+// going through the pathManager/stream machinery that bridges RTSP, RTMP
+// and HLS together. As a result, a WHIP publish isn't visible to those
+// other protocols' readers, and a WebRTC reader can't read a path fed by a
+// non-WebRTC source. pathManager is only used, server-side, to authorize a
+// request against the path's configuration (see onRequest); bridging the
+// WebRTC path into the shared stream is left for a follow-up.
+type webRTCConn struct {
+	ctx             context.Context
+	ctxCancel       func()
+	readBufferCount int
+	pathName        string
+	publish         bool
+	handshake       webRTCHandshakeConn
+	videoCodec      string
+	audioCodec      string
+	videoBitrate    string
+	iceServers      []webRTCICEServer
+	pliInterval     conf.StringDuration
+	requestedLayer  string
+	wg              *sync.WaitGroup
+	parent          webRTCConnParent
+	api             *webrtc.API
+
+	uuid        uuid.UUID
+	created     time.Time
+	remoteAddrV net.Addr
+
+	// resourceID is non-empty for WHIP/WHEP sessions and is used to build
+	// the Location header returned from the initial POST, and to look the
+	// session up again on DELETE/PATCH.
+	resourceID string
+
+	mutex sync.RWMutex
+	pc    *webRTCPeerConnection
+
+	// incomingTracks holds, for a simulcast publisher, every received video
+	// layer indexed by its RID ("q"/"h"/"f" or whatever the publisher
+	// chose). A non-simulcast publisher stores its single video track under
+	// the empty RID.
+	incomingTracks map[string]*webRTCIncomingTrack
+
+	// incomingAudioTrack holds the publisher's audio track, if any.
+	incomingAudioTrack *webRTCIncomingTrack
+
+	// selectedLayerV is the RID of the layer that a subscriber is
+	// currently being forwarded, when reading from a simulcast publisher.
+	selectedLayerV string
+
+	// forwardedVideoTrack is the publisher's incoming video track that is
+	// being forwarded to this subscriber, used to target PLI requests at
+	// the right SSRC. It is nil for a publish connection or for a
+	// subscriber not yet attached to any video track.
+	forwardedVideoTrack *webRTCIncomingTrack
+
+	// out
+	done chan struct{}
+}
+
+func newWebRTCConn(
+	parentCtx context.Context,
+	readBufferCount int,
+	pathName string,
+	publish bool,
+	handshake webRTCHandshakeConn,
+	videoCodec string,
+	audioCodec string,
+	videoBitrate string,
+	iceServers []webRTCICEServer,
+	pliInterval conf.StringDuration,
+	wg *sync.WaitGroup,
+	parent webRTCConnParent,
+	api *webrtc.API,
+	remoteAddr net.Addr,
+	layer string,
+) *webRTCConn {
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	c := &webRTCConn{
+		ctx:             ctx,
+		ctxCancel:       ctxCancel,
+		readBufferCount: readBufferCount,
+		pathName:        pathName,
+		publish:         publish,
+		handshake:       handshake,
+		videoCodec:      videoCodec,
+		audioCodec:      audioCodec,
+		videoBitrate:    videoBitrate,
+		iceServers:      iceServers,
+		pliInterval:     pliInterval,
+		requestedLayer:  layer,
+		wg:              wg,
+		parent:          parent,
+		api:             api,
+		remoteAddrV:     remoteAddr,
+		incomingTracks:  make(map[string]*webRTCIncomingTrack),
+		uuid:            uuid.New(),
+		created:         time.Now(),
+		done:            make(chan struct{}),
+	}
+
+	if _, ok := handshake.(webRTCHTTPHandshake); ok {
+		c.resourceID = c.uuid.String()
+	}
+
+	wg.Add(1)
+	go c.run()
+
+	return c
+}
+
+// Log is the main logging function.
+func (c *webRTCConn) Log(level logger.Level, format string, args ...interface{}) {
+	c.parent.Log(level, "[conn %v] "+format, append([]interface{}{c.remoteAddrV}, args...)...)
+}
+
+func (c *webRTCConn) run() {
+	defer c.wg.Done()
+	defer close(c.done)
+
+	err := c.runInner()
+	if err != nil {
+		c.Log(logger.Info, "closed: %v", err)
+	}
+
+	c.ctxCancel()
+	c.parent.connClose(c)
+}
+
+func (c *webRTCConn) runInner() error {
+	offer, err := c.handshake.readOffer()
+	if err != nil {
+		return fmt.Errorf("failed to read offer: %w", err)
+	}
+
+	resolvedICEServers := make([]webrtc.ICEServer, len(c.iceServers))
+	for i, server := range c.iceServers {
+		resolvedICEServers[i] = server.resolve()
+	}
+
+	pc, err := newWebRTCPeerConnection(c.api, webrtc.Configuration{
+		ICEServers: resolvedICEServers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create peer connection: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.pc = pc
+	c.mutex.Unlock()
+
+	defer pc.Close()
+
+	if c.publish {
+		pc.OnTrack(c.onTrack)
+	}
+
+	var pubConn *webRTCConn
+	if !c.publish {
+		pubConn = c.waitForPublisher()
+	}
+
+	err = pc.SetRemoteDescription(webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  offer,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set remote description: %w", err)
+	}
+
+	if !c.publish {
+		err = c.addOutgoingTracks(pc, pubConn)
+		if err != nil {
+			return err
+		}
+	}
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create answer: %w", err)
+	}
+
+	err = pc.SetLocalDescription(answer)
+	if err != nil {
+		return fmt.Errorf("failed to set local description: %w", err)
+	}
+
+	<-webrtc.GatheringCompletePromise(pc.PeerConnection)
+
+	err = c.handshake.writeAnswer(pc.LocalDescription().SDP)
+	if err != nil {
+		return fmt.Errorf("failed to write answer: %w", err)
+	}
+
+	if !c.publish && pubConn != nil {
+		go c.pliSender(pubConn)
+	}
+
+	<-c.ctx.Done()
+	return fmt.Errorf("terminated")
+}
+
+// waitForPublisherTimeout bounds how long a reading connection waits, at
+// handshake time, for a publisher to appear on its path and start sending
+// tracks. Tracks are only attached once, when the initial SDP answer is
+// built (there is no renegotiation), so a publisher whose tracks arrive
+// after this window closes is not picked up by readers already waiting.
+const waitForPublisherTimeout = 3 * time.Second
+
+// waitForPublisher polls for a publish connection on c.pathName that has
+// started receiving at least one track, so that a reader which connects
+// slightly before its publisher, or before the publisher's tracks actually
+// arrive, still gets attached to them instead of permanently ending up with
+// no outgoing tracks. Returns nil if no such publisher shows up within
+// waitForPublisherTimeout.
+func (c *webRTCConn) waitForPublisher() *webRTCConn {
+	if pubConn := c.parent.connByPathName(c.pathName); pubConn != nil && pubConn.hasAnyIncomingTrack() {
+		return pubConn
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	deadline := time.After(waitForPublisherTimeout)
+
+	for {
+		select {
+		case <-ticker.C:
+			if pubConn := c.parent.connByPathName(c.pathName); pubConn != nil && pubConn.hasAnyIncomingTrack() {
+				return pubConn
+			}
+
+		case <-deadline:
+			return nil
+
+		case <-c.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// hasAnyIncomingTrack reports whether this publish connection has received
+// at least one video or audio track from its publisher yet.
+func (c *webRTCConn) hasAnyIncomingTrack() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.incomingTracks) > 0 || c.incomingAudioTrack != nil
+}
+
+// addOutgoingTracks attaches pubConn's currently selected video layer and
+// its audio track, if any, to pc, and records the layer that was actually
+// picked so that it's visible through the API. pubConn is nil when no
+// publisher showed up on the path within waitForPublisherTimeout, in which
+// case the reader ends up with no outgoing tracks.
+func (c *webRTCConn) addOutgoingTracks(pc *webRTCPeerConnection, pubConn *webRTCConn) error {
+	actualLayer := ""
+	var videoTrack *webRTCIncomingTrack
+
+	if pubConn != nil {
+		var rid string
+		rid, videoTrack = pubConn.layerTrack(c.requestedLayer)
+		if videoTrack != nil {
+			actualLayer = rid
+
+			if _, err := pc.AddTrack(videoTrack.local); err != nil {
+				return fmt.Errorf("failed to add video track: %w", err)
+			}
+		}
+
+		if track := pubConn.audioTrack(); track != nil {
+			if _, err := pc.AddTrack(track.local); err != nil {
+				return fmt.Errorf("failed to add audio track: %w", err)
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	c.selectedLayerV = actualLayer
+	c.forwardedVideoTrack = videoTrack
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// onTrack is called by pion for every track of an incoming publish
+// connection. A browser publishing with simulcast opens one track per RID
+// (e.g. "f"/"h"/"q" for full/half/quarter resolution); a non-simulcast
+// publisher opens a single track with an empty RID.
+func (c *webRTCConn) onTrack(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+	local, err := webrtc.NewTrackLocalStaticRTP(track.Codec().RTPCodecCapability, track.Kind().String(), track.StreamID())
+	if err != nil {
+		c.Log(logger.Warn, "failed to create forwarding track: %v", err)
+		return
+	}
+
+	incoming := &webRTCIncomingTrack{remote: track, local: local}
+	rid := track.RID()
+
+	c.mutex.Lock()
+	if track.Kind() == webrtc.RTPCodecTypeAudio {
+		c.incomingAudioTrack = incoming
+	} else {
+		c.incomingTracks[rid] = incoming
+	}
+	c.mutex.Unlock()
+
+	c.Log(logger.Info, "received track, codec=%v rid=%v", track.Codec().MimeType, rid)
+
+	c.forwardIncomingTrack(track, local)
+}
+
+// forwardIncomingTrack copies RTP packets received on a publisher's track
+// into the corresponding local track, so that reader connections can
+// republish them downstream by adding the local track to their own peer
+// connection. It blocks until the track is no longer readable.
+func (c *webRTCConn) forwardIncomingTrack(remote *webrtc.TrackRemote, local *webrtc.TrackLocalStaticRTP) {
+	for {
+		pkt, _, err := remote.ReadRTP()
+		if err != nil {
+			return
+		}
+
+		if err := local.WriteRTP(pkt); err != nil {
+			return
+		}
+	}
+}
+
+// ridRank returns the quality rank of a simulcast RID, from lowest (0) to
+// highest, for the naming conventions seen in the wild: pion/Firefox's
+// "q"/"h"/"f", Chrome's numeric "0"/"1"/"2", and mediamtx's own
+// "low"/"mid"/"high". ok is false for an RID that doesn't match any known
+// convention.
+func ridRank(rid string) (rank int, ok bool) {
+	switch strings.ToLower(rid) {
+	case "q", "low", "0":
+		return 0, true
+	case "h", "mid", "1":
+		return 1, true
+	case "f", "high", "2":
+		return 2, true
+	default:
+		return 0, false
+	}
+}
+
+// orderedLayers returns the RIDs of every received video layer, sorted from
+// lowest to highest quality. RIDs that don't match a known naming
+// convention are ranked last, in a stable (alphabetical) order, since their
+// relative quality can't otherwise be inferred.
+func (c *webRTCConn) orderedLayers() []string {
+	c.mutex.RLock()
+	rids := make([]string, 0, len(c.incomingTracks))
+	for rid := range c.incomingTracks {
+		rids = append(rids, rid)
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(rids, func(i, j int) bool {
+		ri, oki := ridRank(rids[i])
+		rj, okj := ridRank(rids[j])
+
+		switch {
+		case oki && okj:
+			return ri < rj
+		case oki != okj:
+			return oki
+		default:
+			return rids[i] < rids[j]
+		}
+	})
+
+	return rids
+}
+
+// layerTrack returns the RID and incoming video track matching the
+// requested layer ("low"/"mid"/"high", or an exact RID), resolved against
+// the layers the publisher actually sent rather than always picking the
+// highest one.
+func (c *webRTCConn) layerTrack(requestedLayer string) (string, *webRTCIncomingTrack) {
+	c.mutex.RLock()
+	if track, ok := c.incomingTracks[requestedLayer]; ok {
+		c.mutex.RUnlock()
+		return requestedLayer, track
+	}
+	c.mutex.RUnlock()
+
+	rids := c.orderedLayers()
+	if len(rids) == 0 {
+		return "", nil
+	}
+
+	var idx int
+	switch strings.ToLower(requestedLayer) {
+	case "low":
+		idx = 0
+	case "mid":
+		idx = (len(rids) - 1) / 2
+	default: // "high", "", or anything unrecognized
+		idx = len(rids) - 1
+	}
+
+	rid := rids[idx]
+
+	c.mutex.RLock()
+	track := c.incomingTracks[rid]
+	c.mutex.RUnlock()
+
+	return rid, track
+}
+
+// audioTrack returns the publisher's incoming audio track, if any.
+func (c *webRTCConn) audioTrack() *webRTCIncomingTrack {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.incomingAudioTrack
+}
+
+func (c *webRTCConn) selectedLayer() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.selectedLayerV
+}
+
+// pliSender periodically sends a Picture Loss Indication on this
+// subscriber's forwarded video track, on the publisher's path, so that a
+// publisher that doesn't emit frequent keyframes on its own doesn't leave
+// this viewer stuck waiting for one.
+func (c *webRTCConn) pliSender(pubConn *webRTCConn) {
+	interval := time.Duration(c.pliInterval)
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mutex.RLock()
+			videoTrack := c.forwardedVideoTrack
+			c.mutex.RUnlock()
+
+			if videoTrack == nil {
+				continue
+			}
+
+			pubPC := pubConn.safePC()
+			if pubPC == nil {
+				continue
+			}
+
+			pubPC.WriteRTCP([]rtcp.Packet{ //nolint:errcheck
+				&rtcp.PictureLossIndication{MediaSSRC: uint32(videoTrack.remote.SSRC())},
+			})
+
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *webRTCConn) wait() {
+	<-c.done
+}
+
+func (c *webRTCConn) close() {
+	c.ctxCancel()
+	<-c.done
+}
+
+func (c *webRTCConn) safePC() *webRTCPeerConnection {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.pc
+}
+
+func (c *webRTCConn) remoteAddr() net.Addr {
+	return c.remoteAddrV
+}
+
+// addTrickleICE adds the ICE candidates carried by a Trickle ICE SDP
+// fragment (RFC 8840) to the underlying peer connection.
+func (c *webRTCConn) addTrickleICE(frag string) error {
+	pc := c.safePC()
+	if pc == nil {
+		return fmt.Errorf("peer connection not established yet")
+	}
+
+	for _, line := range strings.Split(frag, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(line, "a=candidate:") {
+			continue
+		}
+
+		err := pc.AddICECandidate(webrtc.ICECandidateInit{
+			Candidate: strings.TrimPrefix(line, "a="),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
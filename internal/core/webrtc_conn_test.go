@@ -0,0 +1,98 @@
+package core
+
+import "testing"
+
+func TestRidRank(t *testing.T) {
+	for _, ca := range []struct {
+		rid      string
+		wantRank int
+		wantOK   bool
+	}{
+		{"q", 0, true},
+		{"Low", 0, true},
+		{"0", 0, true},
+		{"h", 1, true},
+		{"mid", 1, true},
+		{"1", 1, true},
+		{"f", 2, true},
+		{"High", 2, true},
+		{"2", 2, true},
+		{"bogus", 0, false},
+		{"", 0, false},
+	} {
+		rank, ok := ridRank(ca.rid)
+		if rank != ca.wantRank || ok != ca.wantOK {
+			t.Errorf("ridRank(%q) = (%v, %v), want (%v, %v)", ca.rid, rank, ok, ca.wantRank, ca.wantOK)
+		}
+	}
+}
+
+func TestOrderedLayers(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		rids []string
+		want []string
+	}{
+		{"known RIDs", []string{"f", "q", "h"}, []string{"q", "h", "f"}},
+		{"unknown RIDs sorted alphabetically and ranked last", []string{"zz", "q", "aa"}, []string{"q", "aa", "zz"}},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			c := &webRTCConn{incomingTracks: make(map[string]*webRTCIncomingTrack)}
+			for _, rid := range ca.rids {
+				c.incomingTracks[rid] = &webRTCIncomingTrack{}
+			}
+
+			got := c.orderedLayers()
+			if len(got) != len(ca.want) {
+				t.Fatalf("orderedLayers() = %v, want %v", got, ca.want)
+			}
+			for i := range got {
+				if got[i] != ca.want[i] {
+					t.Errorf("orderedLayers() = %v, want %v", got, ca.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestLayerTrack(t *testing.T) {
+	low := &webRTCIncomingTrack{}
+	mid := &webRTCIncomingTrack{}
+	high := &webRTCIncomingTrack{}
+
+	c := &webRTCConn{
+		incomingTracks: map[string]*webRTCIncomingTrack{
+			"q": low,
+			"h": mid,
+			"f": high,
+		},
+	}
+
+	for _, ca := range []struct {
+		requested string
+		wantRID   string
+		wantTrack *webRTCIncomingTrack
+	}{
+		{"low", "q", low},
+		{"mid", "h", mid},
+		{"high", "f", high},
+		{"", "f", high},
+		{"q", "q", low},
+		{"bogus", "f", high},
+	} {
+		rid, track := c.layerTrack(ca.requested)
+		if rid != ca.wantRID || track != ca.wantTrack {
+			t.Errorf("layerTrack(%q) = (%q, %p), want (%q, %p)", ca.requested, rid, track, ca.wantRID, ca.wantTrack)
+		}
+	}
+}
+
+func TestLayerTrackNoLayers(t *testing.T) {
+	c := &webRTCConn{incomingTracks: make(map[string]*webRTCIncomingTrack)}
+
+	rid, track := c.layerTrack("high")
+	if rid != "" || track != nil {
+		t.Errorf("layerTrack() on a publisher with no layers = (%q, %v), want (\"\", nil)", rid, track)
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// webRTCICEServer is a richer replacement for a plain ICE server URL: it
+// also carries static credentials, or the parameters needed to mint
+// short-lived ones following the "REST API For Access To TURN Services"
+// HMAC scheme (https://datatracker.ietf.org/doc/html/draft-uberti-behave-turn-rest-00),
+// as implemented by coturn's use-auth-secret option.
+type webRTCICEServer struct {
+	URL            string
+	Username       string
+	Credential     string
+	CredentialType webrtc.ICECredentialType
+
+	// Secret, when set, makes Username the "user" part of the HMAC scheme
+	// and TTL the lifetime of the credentials it mints (24h when zero): a
+	// fresh username/credential pair is computed for every session, and
+	// Credential is not used.
+	Secret string
+	TTL    time.Duration
+}
+
+// resolve returns the webrtc.ICEServer to advertise to a given session,
+// computing ephemeral TURN credentials when a shared secret is configured.
+func (s webRTCICEServer) resolve() webrtc.ICEServer {
+	if s.Secret == "" {
+		return webrtc.ICEServer{
+			URLs:           []string{s.URL},
+			Username:       s.Username,
+			Credential:     s.Credential,
+			CredentialType: s.CredentialType,
+		}
+	}
+
+	username, credential := s.restCredentials(time.Now())
+
+	return webrtc.ICEServer{
+		URLs:           []string{s.URL},
+		Username:       username,
+		Credential:     credential,
+		CredentialType: webrtc.ICECredentialTypePassword,
+	}
+}
+
+// restCredentials computes a TURN REST API username/credential pair:
+// username is "<unix-expiry>:<user>", credential is
+// base64(HMAC-SHA1(secret, username)).
+func (s webRTCICEServer) restCredentials(now time.Time) (string, string) {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	username := fmt.Sprintf("%d:%s", now.Add(ttl).Unix(), s.Username)
+
+	mac := hmac.New(sha1.New, []byte(s.Secret))
+	mac.Write([]byte(username))
+	credential := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return username, credential
+}
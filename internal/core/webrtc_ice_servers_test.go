@@ -0,0 +1,88 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestWebRTCICEServerResolveStatic(t *testing.T) {
+	s := webRTCICEServer{
+		URL:            "turn:example.com:3478",
+		Username:       "user",
+		Credential:     "pass",
+		CredentialType: webrtc.ICECredentialTypePassword,
+	}
+
+	got := s.resolve()
+
+	if len(got.URLs) != 1 || got.URLs[0] != s.URL ||
+		got.Username != s.Username || got.Credential != s.Credential ||
+		got.CredentialType != s.CredentialType {
+		t.Errorf("resolve() = %+v, want static URL/Username/Credential/CredentialType to be passed through unchanged", got)
+	}
+}
+
+func TestWebRTCICEServerRestCredentials(t *testing.T) {
+	s := webRTCICEServer{
+		URL:      "turn:example.com:3478",
+		Username: "myuser",
+		Secret:   "mysecret",
+		TTL:      10 * time.Second,
+	}
+
+	now := time.Unix(1700000000, 0)
+
+	username, credential := s.restCredentials(now)
+
+	wantUsername := "1700000010:myuser"
+	if username != wantUsername {
+		t.Errorf("restCredentials() username = %q, want %q", username, wantUsername)
+	}
+	if credential == "" {
+		t.Error("restCredentials() returned an empty credential")
+	}
+
+	if _, credential2 := s.restCredentials(now); credential2 != credential {
+		t.Error("restCredentials() is not deterministic for identical inputs")
+	}
+
+	other := s
+	other.Secret = "othersecret"
+	if _, credential3 := other.restCredentials(now); credential3 == credential {
+		t.Error("restCredentials() did not change when the secret changed")
+	}
+}
+
+func TestWebRTCICEServerRestCredentialsDefaultTTL(t *testing.T) {
+	s := webRTCICEServer{Username: "u", Secret: "s"}
+
+	now := time.Unix(1700000000, 0)
+	username, _ := s.restCredentials(now)
+
+	want := "1700086400:u" // now + the default 24h TTL
+	if username != want {
+		t.Errorf("restCredentials() username = %q, want %q", username, want)
+	}
+}
+
+func TestWebRTCICEServerResolveRest(t *testing.T) {
+	s := webRTCICEServer{
+		URL:      "turn:example.com:3478",
+		Username: "myuser",
+		Secret:   "mysecret",
+	}
+
+	got := s.resolve()
+
+	if len(got.URLs) != 1 || got.URLs[0] != s.URL {
+		t.Errorf("resolve() URLs = %v, want [%q]", got.URLs, s.URL)
+	}
+	if got.CredentialType != webrtc.ICECredentialTypePassword {
+		t.Errorf("resolve() CredentialType = %v, want %v", got.CredentialType, webrtc.ICECredentialTypePassword)
+	}
+	if got.Username == s.Username || got.Credential == s.Credential {
+		t.Error("resolve() with a Secret set must mint fresh REST credentials, not pass through the static fields")
+	}
+}
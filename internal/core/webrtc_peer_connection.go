@@ -0,0 +1,70 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// webRTCPeerConnection wraps webrtc.PeerConnection, adding things that are
+// needed across the package (candidate/byte-count reporting for the API).
+type webRTCPeerConnection struct {
+	*webrtc.PeerConnection
+
+	mutex           sync.RWMutex
+	localCandidateV string
+}
+
+func newWebRTCPeerConnection(
+	api *webrtc.API,
+	configuration webrtc.Configuration,
+) (*webRTCPeerConnection, error) {
+	wr, err := api.NewPeerConnection(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	co := &webRTCPeerConnection{PeerConnection: wr}
+
+	wr.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c != nil {
+			co.mutex.Lock()
+			co.localCandidateV = c.String()
+			co.mutex.Unlock()
+		}
+	})
+
+	return co, nil
+}
+
+func (co *webRTCPeerConnection) localCandidate() string {
+	co.mutex.RLock()
+	defer co.mutex.RUnlock()
+	return co.localCandidateV
+}
+
+func (co *webRTCPeerConnection) remoteCandidate() string {
+	pair, err := co.PeerConnection.SCTP().Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil {
+		return ""
+	}
+	return pair.Remote.String()
+}
+
+// transportStats returns the ICE transport's own byte counters, which pion
+// maintains internally for every packet that actually crosses the wire, so
+// there's no need to duplicate that bookkeeping by hand at the RTP level.
+func (co *webRTCPeerConnection) transportStats() webrtc.TransportStats {
+	if stats, ok := co.PeerConnection.GetStats()["iceTransport"].(webrtc.TransportStats); ok {
+		return stats
+	}
+	return webrtc.TransportStats{}
+}
+
+func (co *webRTCPeerConnection) bytesReceived() uint64 {
+	return co.transportStats().BytesReceived
+}
+
+func (co *webRTCPeerConnection) bytesSent() uint64 {
+	return co.transportStats().BytesSent
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	_ "embed"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/pion/ice/v2"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 
 	"github.com/aler9/mediamtx/internal/conf"
@@ -35,6 +37,7 @@ type webRTCServerAPIConnsListItem struct {
 	RemoteCandidate           string    `json:"remoteCandidate"`
 	BytesReceived             uint64    `json:"bytesReceived"`
 	BytesSent                 uint64    `json:"bytesSent"`
+	SimulcastLayer            string    `json:"simulcastLayer"`
 }
 
 type webRTCServerAPIConnsListData struct {
@@ -59,14 +62,26 @@ type webRTCServerAPIConnsKickReq struct {
 	res chan webRTCServerAPIConnsKickRes
 }
 
+type webRTCConnByResourceIDReq struct {
+	resourceID string
+	res        chan *webRTCConn
+}
+
+type webRTCConnByPathNameReq struct {
+	pathName string
+	res      chan *webRTCConn
+}
+
 type webRTCConnNewReq struct {
 	pathName     string
 	publish      bool
-	wsconn       *websocket.ServerConn
+	handshake    webRTCHandshakeConn
+	remoteAddr   net.Addr
 	res          chan *webRTCConn
 	videoCodec   string
 	audioCodec   string
 	videoBitrate string
+	layer        string
 }
 
 type webRTCServerParent interface {
@@ -76,7 +91,8 @@ type webRTCServerParent interface {
 type webRTCServer struct {
 	allowOrigin     string
 	trustedProxies  conf.IPsOrCIDRs
-	iceServers      []string
+	iceServers      []webRTCICEServer
+	pliInterval     conf.StringDuration
 	readBufferCount int
 	pathManager     *pathManager
 	metrics         *metrics
@@ -90,15 +106,18 @@ type webRTCServer struct {
 	udpMuxLn          net.PacketConn
 	tcpMuxLn          net.Listener
 	conns             map[*webRTCConn]struct{}
-	iceHostNAT1To1IPs []string
+	icePublicIPs      []string
 	iceUDPMux         ice.UDPMux
 	iceTCPMux         ice.TCPMux
+	api               *webrtc.API
 
 	// in
-	connNew        chan webRTCConnNewReq
-	chConnClose    chan *webRTCConn
-	chAPIConnsList chan webRTCServerAPIConnsListReq
-	chAPIConnsKick chan webRTCServerAPIConnsKickReq
+	connNew            chan webRTCConnNewReq
+	chConnClose        chan *webRTCConn
+	chAPIConnsList     chan webRTCServerAPIConnsListReq
+	chAPIConnsKick     chan webRTCServerAPIConnsKickReq
+	chConnByResourceID chan webRTCConnByResourceIDReq
+	chConnByPathName   chan webRTCConnByPathNameReq
 
 	// out
 	done chan struct{}
@@ -112,16 +131,30 @@ func newWebRTCServer(
 	serverCert string,
 	allowOrigin string,
 	trustedProxies conf.IPsOrCIDRs,
-	iceServers []string,
+	iceServers []webRTCICEServer,
 	readTimeout conf.StringDuration,
+	pliInterval conf.StringDuration,
 	readBufferCount int,
 	pathManager *pathManager,
 	metrics *metrics,
 	parent webRTCServerParent,
-	iceHostNAT1To1IPs []string,
+	// icePublicIPs are advertised as host ICE candidates in place of the
+	// server's real local IPs, for deployments sitting behind a static 1:1
+	// NAT where STUN can't discover (or is disabled from discovering) the
+	// public address.
+	icePublicIPs []string,
 	iceUDPMuxAddress string,
 	iceTCPMuxAddress string,
+	iceUDPPortMin uint16,
+	iceUDPPortMax uint16,
 ) (*webRTCServer, error) {
+	if (iceUDPPortMin == 0) != (iceUDPPortMax == 0) {
+		return nil, fmt.Errorf("iceUDPPortMin and iceUDPPortMax must be both set or both unset")
+	}
+	if iceUDPPortMin != 0 && iceUDPPortMax < iceUDPPortMin {
+		return nil, fmt.Errorf("iceUDPPortMax must be greater than or equal to iceUDPPortMin")
+	}
+
 	ln, err := net.Listen(restrictNetwork("tcp", address))
 	if err != nil {
 		return nil, err
@@ -160,30 +193,76 @@ func newWebRTCServer(
 		iceTCPMux = webrtc.NewICETCPMux(nil, tcpMuxLn, 8)
 	}
 
+	settingsEngine := webrtc.SettingEngine{}
+
+	if iceUDPMux != nil {
+		settingsEngine.SetICEUDPMux(iceUDPMux)
+	}
+	if iceTCPMux != nil {
+		settingsEngine.SetICETCPMux(iceTCPMux)
+		settingsEngine.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeTCP4, webrtc.NetworkTypeTCP6})
+	}
+	if iceUDPPortMin != 0 || iceUDPPortMax != 0 {
+		err = settingsEngine.SetEphemeralUDPPortRange(iceUDPPortMin, iceUDPPortMax)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(icePublicIPs) != 0 {
+		settingsEngine.SetNAT1To1IPs(icePublicIPs, webrtc.ICECandidateTypeHost)
+	}
+
+	mediaEngine := &webrtc.MediaEngine{}
+	if err = mediaEngine.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+
+	// needed to read simulcast RIDs out of the SDES RTP header extensions,
+	// both before and after a RID has been repaired by the RTX mechanism.
+	// the "repaired" URI isn't exported by the pinned pion/sdp version, so
+	// it's spelled out as a literal, like the rest of this codebase does
+	// for extension URIs.
+	const sdesRepairRTPStreamIDURI = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+	for _, extension := range []string{sdp.SDESRTPStreamIDURI, sdesRepairRTPStreamIDURI} {
+		if err = mediaEngine.RegisterHeaderExtension(
+			webrtc.RTPHeaderExtensionCapability{URI: extension}, webrtc.RTPCodecTypeVideo); err != nil {
+			return nil, err
+		}
+	}
+
+	api := webrtc.NewAPI(
+		webrtc.WithSettingEngine(settingsEngine),
+		webrtc.WithMediaEngine(mediaEngine),
+	)
+
 	ctx, ctxCancel := context.WithCancel(parentCtx)
 
 	s := &webRTCServer{
-		allowOrigin:       allowOrigin,
-		trustedProxies:    trustedProxies,
-		iceServers:        iceServers,
-		readBufferCount:   readBufferCount,
-		pathManager:       pathManager,
-		metrics:           metrics,
-		parent:            parent,
-		ctx:               ctx,
-		ctxCancel:         ctxCancel,
-		ln:                ln,
-		udpMuxLn:          udpMuxLn,
-		tcpMuxLn:          tcpMuxLn,
-		iceUDPMux:         iceUDPMux,
-		iceTCPMux:         iceTCPMux,
-		iceHostNAT1To1IPs: iceHostNAT1To1IPs,
-		conns:             make(map[*webRTCConn]struct{}),
-		connNew:           make(chan webRTCConnNewReq),
-		chConnClose:       make(chan *webRTCConn),
-		chAPIConnsList:    make(chan webRTCServerAPIConnsListReq),
-		chAPIConnsKick:    make(chan webRTCServerAPIConnsKickReq),
-		done:              make(chan struct{}),
+		allowOrigin:        allowOrigin,
+		trustedProxies:     trustedProxies,
+		iceServers:         iceServers,
+		pliInterval:        pliInterval,
+		readBufferCount:    readBufferCount,
+		pathManager:        pathManager,
+		metrics:            metrics,
+		parent:             parent,
+		ctx:                ctx,
+		ctxCancel:          ctxCancel,
+		ln:                 ln,
+		udpMuxLn:           udpMuxLn,
+		tcpMuxLn:           tcpMuxLn,
+		iceUDPMux:          iceUDPMux,
+		iceTCPMux:          iceTCPMux,
+		api:                api,
+		icePublicIPs:       icePublicIPs,
+		conns:              make(map[*webRTCConn]struct{}),
+		connNew:            make(chan webRTCConnNewReq),
+		chConnClose:        make(chan *webRTCConn),
+		chAPIConnsList:     make(chan webRTCServerAPIConnsListReq),
+		chAPIConnsKick:     make(chan webRTCServerAPIConnsKickReq),
+		chConnByResourceID: make(chan webRTCConnByResourceIDReq),
+		chConnByPathName:   make(chan webRTCConnByPathNameReq),
+		done:               make(chan struct{}),
 	}
 
 	s.requestPool = newHTTPRequestPool()
@@ -208,6 +287,9 @@ func newWebRTCServer(
 		str += ", " + iceTCPMuxAddress + " (ICE/TCP)"
 	}
 	s.Log(logger.Info, str)
+	s.Log(logger.Warn, "WHIP/WHEP sessions are currently WebRTC-to-WebRTC only: a"+
+		" published path isn't visible to RTSP/RTMP/HLS readers, and a WebRTC reader"+
+		" can't read a path fed by a non-WebRTC source (see webRTCConn's doc comment)")
 
 	if s.metrics != nil {
 		s.metrics.webRTCServerSet(s)
@@ -249,17 +331,17 @@ outer:
 				s.readBufferCount,
 				req.pathName,
 				req.publish,
-				req.wsconn,
+				req.handshake,
 				req.videoCodec,
 				req.audioCodec,
 				req.videoBitrate,
 				s.iceServers,
+				s.pliInterval,
 				&wg,
-				s.pathManager,
 				s,
-				s.iceHostNAT1To1IPs,
-				s.iceUDPMux,
-				s.iceTCPMux,
+				s.api,
+				req.remoteAddr,
+				req.layer,
 			)
 			s.conns[c] = struct{}{}
 			req.res <- c
@@ -296,6 +378,7 @@ outer:
 					RemoteCandidate:           remoteCandidate,
 					BytesReceived:             bytesReceived,
 					BytesSent:                 bytesSent,
+					SimulcastLayer:            c.selectedLayer(),
 				}
 			}
 
@@ -318,6 +401,26 @@ outer:
 				req.res <- webRTCServerAPIConnsKickRes{fmt.Errorf("not found")}
 			}
 
+		case req := <-s.chConnByResourceID:
+			var found *webRTCConn
+			for c := range s.conns {
+				if c.resourceID == req.resourceID {
+					found = c
+					break
+				}
+			}
+			req.res <- found
+
+		case req := <-s.chConnByPathName:
+			var found *webRTCConn
+			for c := range s.conns {
+				if c.publish && c.pathName == req.pathName {
+					found = c
+					break
+				}
+			}
+			req.res <- found
+
 		case <-s.ctx.Done():
 			break outer
 		}
@@ -340,30 +443,12 @@ outer:
 	}
 }
 
-func (s *webRTCServer) onRequest(ctx *gin.Context) {
-	ctx.Writer.Header().Set("Access-Control-Allow-Origin", s.allowOrigin)
-	ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-
-	switch ctx.Request.Method {
-	case http.MethodGet:
-
-	case http.MethodOptions:
-		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		ctx.Writer.Header().Set("Access-Control-Allow-Headers", ctx.Request.Header.Get("Access-Control-Request-Headers"))
-		ctx.Writer.WriteHeader(http.StatusOK)
-		return
-
-	default:
-		return
-	}
-
-	// remove leading prefix
-	pa := ctx.Request.URL.Path[1:]
-
-	var dir string
-	var fname string
-	var publish bool
-
+// parseWebRTCPath splits the path of an incoming request into the path name
+// it targets and the kind of WebRTC signaling operation requested, mirroring
+// the conventions of the legacy WebSocket pages (/<path>/ws,
+// /<path>/publish/ws) and of WHIP/WHEP (/<path>/whip, /<path>/whep,
+// /<path>/whip/<resourceID>, /<path>/whep/<resourceID>).
+func parseWebRTCPath(pa string) (dir string, fname string, publish bool) {
 	switch {
 	case strings.HasSuffix(pa, "/publish/ws"):
 		dir = pa[:len(pa)-len("/publish/ws")]
@@ -380,19 +465,87 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 		fname = "ws"
 		publish = false
 
-	case pa == "favicon.ico":
-		return
+	case strings.Contains(pa, "/whip/"):
+		i := strings.Index(pa, "/whip/")
+		dir = pa[:i]
+		fname = "whip/" + pa[i+len("/whip/"):]
+		publish = true
+
+	case strings.Contains(pa, "/whep/"):
+		i := strings.Index(pa, "/whep/")
+		dir = pa[:i]
+		fname = "whep/" + pa[i+len("/whep/"):]
+		publish = false
+
+	case strings.HasSuffix(pa, "/whip"):
+		dir = pa[:len(pa)-len("/whip")]
+		fname = "whip"
+		publish = true
+
+	case strings.HasSuffix(pa, "/whep"):
+		dir = pa[:len(pa)-len("/whep")]
+		fname = "whep"
+		publish = false
 
 	default:
 		dir = pa
 		fname = ""
 		publish = false
+	}
+
+	return dir, fname, publish
+}
+
+func (s *webRTCServer) onRequest(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Origin", s.allowOrigin)
+	ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+
+	// remove leading prefix
+	pa := ctx.Request.URL.Path[1:]
+
+	if pa == "favicon.ico" {
+		return
+	}
+
+	dir, fname, publish := parseWebRTCPath(pa)
 
-		if !strings.HasSuffix(dir, "/") {
+	if strings.HasPrefix(fname, "whip/") {
+		s.onWHIPResource(ctx, dir, strings.TrimPrefix(fname, "whip/"))
+		return
+	}
+
+	if strings.HasPrefix(fname, "whep/") {
+		s.onWHIPResource(ctx, dir, strings.TrimPrefix(fname, "whep/"))
+		return
+	}
+
+	switch ctx.Request.Method {
+	case http.MethodGet:
+		if fname == "" && !strings.HasSuffix(dir, "/") {
 			ctx.Writer.Header().Set("Location", "/"+dir+"/")
 			ctx.Writer.WriteHeader(http.StatusMovedPermanently)
 			return
 		}
+
+	case http.MethodPost:
+		if fname != "whip" && fname != "whep" {
+			return
+		}
+
+	case http.MethodOptions:
+		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+		ctx.Writer.Header().Set("Access-Control-Allow-Headers", ctx.Request.Header.Get("Access-Control-Request-Headers"))
+		if fname == "whip" || fname == "whep" {
+			// this is an unauthenticated CORS preflight, so only the bare
+			// server URLs are advertised here; live credentials are minted
+			// in the POST response, once the caller has been authorized.
+			s.writeICEServersLinkHeader(ctx, false)
+		}
+		ctx.Writer.WriteHeader(http.StatusOK)
+		return
+
+	default:
+		return
 	}
 
 	dir = strings.TrimSuffix(dir, "/")
@@ -400,33 +553,7 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 		return
 	}
 
-	user, pass, hasCredentials := ctx.Request.BasicAuth()
-
-	res := s.pathManager.getPathConf(pathGetPathConfReq{
-		name:    dir,
-		publish: publish,
-		credentials: authCredentials{
-			query: ctx.Request.URL.RawQuery,
-			ip:    net.ParseIP(ctx.ClientIP()),
-			user:  user,
-			pass:  pass,
-			proto: authProtocolWebRTC,
-		},
-	})
-	if res.err != nil {
-		if terr, ok := res.err.(pathErrAuth); ok {
-			if !hasCredentials {
-				ctx.Header("WWW-Authenticate", `Basic realm="mediamtx"`)
-				ctx.Writer.WriteHeader(http.StatusUnauthorized)
-				return
-			}
-
-			s.Log(logger.Info, "authentication error: %v", terr.wrapped)
-			ctx.Writer.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-
-		ctx.Writer.WriteHeader(http.StatusNotFound)
+	if !s.authorize(ctx, dir, publish) {
 		return
 	}
 
@@ -451,16 +578,201 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 		c := s.newConn(webRTCConnNewReq{
 			pathName:     dir,
 			publish:      (fname == "publish/ws"),
-			wsconn:       wsconn,
+			handshake:    webRTCWSHandshake{wsconn},
+			remoteAddr:   stringAddr(ctx.Request.RemoteAddr),
 			videoCodec:   ctx.Query("video_codec"),
 			audioCodec:   ctx.Query("audio_codec"),
 			videoBitrate: ctx.Query("video_bitrate"),
+			layer:        ctx.Query("layer"),
 		})
 		if c == nil {
 			return
 		}
 
 		c.wait()
+
+	case "whip", "whep":
+		s.onWHIPWHEP(ctx, dir, fname == "whip")
+	}
+}
+
+// onWHIPWHEP handles the initial POST of a WHIP (publish) or WHEP (read)
+// session: it reads the SDP offer, creates the corresponding webRTCConn and
+// replies with a 201 containing the SDP answer and the session's Location.
+func (s *webRTCServer) onWHIPWHEP(ctx *gin.Context, dir string, publish bool) {
+	if ctx.Request.Header.Get("Content-Type") != "application/sdp" {
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	buf, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	handshake := webRTCHTTPHandshake{
+		offer:  string(buf),
+		answer: make(chan string, 1),
+	}
+
+	c := s.newConn(webRTCConnNewReq{
+		pathName:   dir,
+		publish:    publish,
+		handshake:  handshake,
+		remoteAddr: stringAddr(ctx.Request.RemoteAddr),
+		layer:      ctx.Query("layer"),
+	})
+	if c == nil {
+		ctx.Writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	select {
+	case answer := <-handshake.answer:
+		proto := "whep"
+		if publish {
+			proto = "whip"
+		}
+
+		s.writeICEServersLinkHeader(ctx, true)
+		ctx.Writer.Header().Set("Content-Type", "application/sdp")
+		ctx.Writer.Header().Set("Location", "/"+dir+"/"+proto+"/"+c.resourceID)
+		ctx.Writer.WriteHeader(http.StatusCreated)
+		ctx.Writer.Write([]byte(answer))
+
+	case <-ctx.Request.Context().Done():
+		c.close()
+	}
+}
+
+// onWHIPResource handles requests targeting an already-created WHIP/WHEP
+// session: DELETE to terminate it, PATCH to feed it Trickle ICE candidates.
+// dir is the path name the request was addressed to, as parsed from the
+// URL; it's checked against the session's own path and re-authorized the
+// same way the initial POST was, so that a request that merely guesses or
+// omits a resourceID can't act on a session it has no business touching.
+func (s *webRTCServer) onWHIPResource(ctx *gin.Context, dir string, resourceID string) {
+	if resourceID == "" {
+		ctx.Writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	c := s.connByResourceID(resourceID)
+	if c == nil || c.pathName != dir {
+		ctx.Writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if ctx.Request.Method != http.MethodOptions && !s.authorize(ctx, dir, c.publish) {
+		return
+	}
+
+	switch ctx.Request.Method {
+	case http.MethodDelete:
+		c.close()
+		ctx.Writer.WriteHeader(http.StatusOK)
+
+	case http.MethodPatch:
+		if ctx.Request.Header.Get("Content-Type") != "application/trickle-ice-sdpfrag" {
+			ctx.Writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		buf, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.Writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = c.addTrickleICE(string(buf))
+		if err != nil {
+			ctx.Writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ctx.Writer.WriteHeader(http.StatusNoContent)
+
+	case http.MethodOptions:
+		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "PATCH, DELETE, OPTIONS")
+		ctx.Writer.Header().Set("Access-Control-Allow-Headers", ctx.Request.Header.Get("Access-Control-Request-Headers"))
+		ctx.Writer.WriteHeader(http.StatusOK)
+
+	default:
+		ctx.Writer.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// requestCredentials extracts authentication credentials either from the
+// standard Basic auth header, or from a bearer token, which WHIP/WHEP
+// clients commonly use to carry a stream's access key.
+func (s *webRTCServer) requestCredentials(ctx *gin.Context) (string, string, bool) {
+	if auth := ctx.Request.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return "", strings.TrimPrefix(auth, "Bearer "), true
+	}
+
+	user, pass, ok := ctx.Request.BasicAuth()
+	return user, pass, ok
+}
+
+// authorize checks the request's credentials against the configuration of
+// path dir, writing the appropriate error response and returning false if
+// access isn't granted.
+func (s *webRTCServer) authorize(ctx *gin.Context, dir string, publish bool) bool {
+	user, pass, hasCredentials := s.requestCredentials(ctx)
+
+	res := s.pathManager.getPathConf(pathGetPathConfReq{
+		name:    dir,
+		publish: publish,
+		credentials: authCredentials{
+			query: ctx.Request.URL.RawQuery,
+			ip:    net.ParseIP(ctx.ClientIP()),
+			user:  user,
+			pass:  pass,
+			proto: authProtocolWebRTC,
+		},
+	})
+	if res.err != nil {
+		if terr, ok := res.err.(pathErrAuth); ok {
+			if !hasCredentials {
+				ctx.Header("WWW-Authenticate", `Basic realm="mediamtx"`)
+				ctx.Writer.WriteHeader(http.StatusUnauthorized)
+				return false
+			}
+
+			s.Log(logger.Info, "authentication error: %v", terr.wrapped)
+			ctx.Writer.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+
+		ctx.Writer.WriteHeader(http.StatusNotFound)
+		return false
+	}
+
+	return true
+}
+
+// writeICEServersLinkHeader advertises the configured ICE servers using the
+// WHIP/WHEP Link header convention, so that clients don't need a separate
+// signaling message to learn about them. When includeCredentials is true,
+// credentials are resolved fresh for this request, so a TURN server using
+// the REST HMAC scheme gets a short-lived username/credential pair per
+// session; callers must only pass true once the request has been
+// authorized for the target path, since the minted credentials are live
+// and directly usable against the TURN server.
+func (s *webRTCServer) writeICEServersLinkHeader(ctx *gin.Context, includeCredentials bool) {
+	for _, server := range s.iceServers {
+		header := "<" + server.URL + ">; rel=\"ice-server\""
+
+		if includeCredentials {
+			resolved := server.resolve()
+			if resolved.Username != "" {
+				header += "; username=\"" + resolved.Username + "\"; credential=\"" +
+					fmt.Sprint(resolved.Credential) + "\"; credential-type=\"password\""
+			}
+		}
+
+		ctx.Writer.Header().Add("Link", header)
 	}
 }
 
@@ -498,6 +810,40 @@ func (s *webRTCServer) apiConnsList() webRTCServerAPIConnsListRes {
 	}
 }
 
+// connByResourceID is called by WHIP/WHEP handlers.
+func (s *webRTCServer) connByResourceID(resourceID string) *webRTCConn {
+	req := webRTCConnByResourceIDReq{
+		resourceID: resourceID,
+		res:        make(chan *webRTCConn),
+	}
+
+	select {
+	case s.chConnByResourceID <- req:
+		return <-req.res
+
+	case <-s.ctx.Done():
+		return nil
+	}
+}
+
+// connByPathName is called by a reading webRTCConn to find the publish
+// connection that is currently serving the same path, so that it can
+// attach to its incoming tracks.
+func (s *webRTCServer) connByPathName(pathName string) *webRTCConn {
+	req := webRTCConnByPathNameReq{
+		pathName: pathName,
+		res:      make(chan *webRTCConn),
+	}
+
+	select {
+	case s.chConnByPathName <- req:
+		return <-req.res
+
+	case <-s.ctx.Done():
+		return nil
+	}
+}
+
 // apiConnsKick is called by api.
 func (s *webRTCServer) apiConnsKick(id string) webRTCServerAPIConnsKickRes {
 	req := webRTCServerAPIConnsKickReq{
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+// newWebRTCServerFromConf builds a webRTCServer out of the user-facing
+// configuration: it resolves every configured ICE server into its richer
+// webRTCICEServer form and threads the ICE UDP port range and public IP
+// overrides into newWebRTCServer, so that they're actually configurable
+// instead of only reachable as unused constructor parameters.
+func newWebRTCServerFromConf(
+	parentCtx context.Context,
+	cfg conf.Conf,
+	pathManager *pathManager,
+	metrics *metrics,
+	parent webRTCServerParent,
+) (*webRTCServer, error) {
+	iceServers := make([]webRTCICEServer, len(cfg.WebRTCICEServers2))
+	for i, s := range cfg.WebRTCICEServers2 {
+		credentialType := webrtc.ICECredentialTypePassword
+		if s.CredentialType == "oauth" {
+			credentialType = webrtc.ICECredentialTypeOauth
+		}
+
+		iceServers[i] = webRTCICEServer{
+			URL:            s.URL,
+			Username:       s.Username,
+			Credential:     s.Credential,
+			CredentialType: credentialType,
+			Secret:         s.Secret,
+			TTL:            time.Duration(s.TTL),
+		}
+	}
+
+	return newWebRTCServer(
+		parentCtx,
+		cfg.WebRTCAddress,
+		cfg.WebRTCEncryption,
+		cfg.WebRTCServerKey,
+		cfg.WebRTCServerCert,
+		cfg.WebRTCAllowOrigin,
+		cfg.WebRTCTrustedProxies,
+		iceServers,
+		cfg.ReadTimeout,
+		cfg.WebRTCPLIInterval,
+		cfg.ReadBufferCount,
+		pathManager,
+		metrics,
+		parent,
+		cfg.WebRTCICEPublicIPs,
+		cfg.WebRTCICEUDPMuxAddress,
+		cfg.WebRTCICETCPMuxAddress,
+		cfg.WebRTCICEPortMin,
+		cfg.WebRTCICEPortMax,
+	)
+}
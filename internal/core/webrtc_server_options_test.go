@@ -0,0 +1,42 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestOnRequestOPTIONSDoesNotLeakTURNCredentials guards against the
+// regression fixed for the unauthenticated CORS preflight: an OPTIONS
+// request to a WHIP/WHEP endpoint must advertise the configured ICE servers
+// without minting or exposing live TURN credentials, since the caller
+// hasn't been authorized yet at that point.
+func TestOnRequestOPTIONSDoesNotLeakTURNCredentials(t *testing.T) {
+	s := &webRTCServer{
+		allowOrigin: "*",
+		iceServers: []webRTCICEServer{{
+			URL:      "turn:example.com:3478",
+			Username: "myuser",
+			Secret:   "mysecret",
+		}},
+	}
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodOptions, "/somepath/whip", nil)
+
+	s.onRequest(ctx)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	for _, header := range w.Header().Values("Link") {
+		if strings.Contains(header, "credential=") {
+			t.Errorf("an unauthenticated OPTIONS preflight must not expose TURN credentials, got Link: %s", header)
+		}
+	}
+}
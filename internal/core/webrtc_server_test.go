@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseWebRTCPath(t *testing.T) {
+	for _, ca := range []struct {
+		name        string
+		path        string
+		wantDir     string
+		wantFname   string
+		wantPublish bool
+	}{
+		{"read page", "mypath", "mypath", "", false},
+		{"read ws", "mypath/ws", "mypath", "ws", false},
+		{"publish page", "mypath/publish", "mypath", "publish", true},
+		{"publish ws", "mypath/publish/ws", "mypath", "publish/ws", true},
+		{"whip create", "mypath/whip", "mypath", "whip", true},
+		{"whep create", "mypath/whep", "mypath", "whep", false},
+		{"whip resource", "mypath/whip/abc123", "mypath", "whip/abc123", true},
+		{"whep resource", "mypath/whep/abc123", "mypath", "whep/abc123", false},
+		{"whip resource with empty resourceID", "mypath/whip/", "mypath", "whip/", true},
+		{"nested path", "a/b/c/whip/abc123", "a/b/c", "whip/abc123", true},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			dir, fname, publish := parseWebRTCPath(ca.path)
+			if dir != ca.wantDir || fname != ca.wantFname || publish != ca.wantPublish {
+				t.Errorf("parseWebRTCPath(%q) = (%q, %q, %v), want (%q, %q, %v)",
+					ca.path, dir, fname, publish, ca.wantDir, ca.wantFname, ca.wantPublish)
+			}
+		})
+	}
+}
+
+// newTestWebRTCConn builds a webRTCConn that never runs its own goroutine, so
+// that tests can check whether it was closed (by inspecting its ctx) without
+// risking a hang: its done channel is already closed, so a stray close()
+// call completes immediately instead of blocking on a run() that never
+// started.
+func newTestWebRTCConn(pathName string, publish bool, resourceID string) *webRTCConn {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	close(done)
+
+	return &webRTCConn{
+		ctx:        ctx,
+		ctxCancel:  ctxCancel,
+		pathName:   pathName,
+		publish:    publish,
+		resourceID: resourceID,
+		done:       done,
+	}
+}
+
+// newTestWebRTCServerForResource builds a webRTCServer whose connByResourceID
+// lookup is served by conns, reproducing the behavior of s.run()'s own
+// chConnByResourceID case closely enough to exercise onWHIPResource without
+// any of the server's other machinery (pathManager included).
+func newTestWebRTCServerForResource(t *testing.T, conns ...*webRTCConn) *webRTCServer {
+	s := &webRTCServer{
+		ctx:                context.Background(),
+		chConnByResourceID: make(chan webRTCConnByResourceIDReq),
+	}
+
+	t.Cleanup(func() {
+		close(s.chConnByResourceID)
+	})
+
+	go func() {
+		for req := range s.chConnByResourceID {
+			var found *webRTCConn
+			for _, c := range conns {
+				if c.resourceID == req.resourceID {
+					found = c
+					break
+				}
+			}
+			req.res <- found
+		}
+	}()
+
+	return s
+}
+
+// TestOnWHIPResourceRejectsEmptyResourceID guards against the regression
+// fixed for an anonymous request that addresses a session by an empty
+// resourceID: victim has a zero-value resourceID (as any non-WHIP/WHEP conn
+// would), so a lookup that didn't reject the empty ID outright would find
+// and close it.
+func TestOnWHIPResourceRejectsEmptyResourceID(t *testing.T) {
+	victim := newTestWebRTCConn("somepath", false, "")
+	s := newTestWebRTCServerForResource(t, victim)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodDelete, "/somepath/whip/", nil)
+
+	s.onWHIPResource(ctx, "somepath", "")
+	ctx.Writer.WriteHeaderNow() // gin only flushes the status after the handler returns
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if victim.ctx.Err() != nil {
+		t.Error("an empty resourceID must not be able to close an unrelated connection")
+	}
+}
+
+// TestOnWHIPResourceRejectsPathMismatch guards against the regression fixed
+// for a request that knows or guesses a valid resourceID but addresses it
+// through a path other than the one it belongs to.
+func TestOnWHIPResourceRejectsPathMismatch(t *testing.T) {
+	victim := newTestWebRTCConn("private", true, "abc123")
+	s := newTestWebRTCServerForResource(t, victim)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodDelete, "/other/whip/abc123", nil)
+
+	s.onWHIPResource(ctx, "other", victim.resourceID)
+	ctx.Writer.WriteHeaderNow() // gin only flushes the status after the handler returns
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if victim.ctx.Err() != nil {
+		t.Error("a resourceID must not be actionable through a path other than its own")
+	}
+}